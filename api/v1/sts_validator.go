@@ -0,0 +1,79 @@
+/*
+Copyright 2024 hsiaoairplane.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/sirupsen/logrus"
+)
+
+//+kubebuilder:webhook:path=/validate--v1-statefulset,mutating=false,failurePolicy=Fail,sideEffects=None,groups="",matchPolicy=Exact,resources=statefulsets,verbs=update,versions=v1,name=vstatefulset.hsiaoairplane.io,admissionReviewVersions=v1
+
+// statefulsetValidator rejects StatefulSet updates that shrink a volumeClaimTemplate's storage
+// request, since PVC size is immutable downwards and the previous behaviour only logged a
+// warning and let the request through.
+//
+// failurePolicy is Fail here, unlike the mutating webhook's Ignore: the mutator has a safe
+// fallback when the webhook server is unreachable (the update just goes through unmutated, same
+// as before this webhook existed), but this validator exists specifically to close off a size
+// decrease slipping through. Ignore would silently reopen that exact hole any time the server is
+// down or misconfigured, which defeats the point of adding it.
+type statefulsetValidator struct{}
+
+func (v *statefulsetValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *statefulsetValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	logger := logrus.New()
+
+	oldSts, ok := oldObj.(*appsv1.StatefulSet)
+	if !ok {
+		return nil, fmt.Errorf("expected a StatefulSet but got a %T", oldObj)
+	}
+	newSts, ok := newObj.(*appsv1.StatefulSet)
+	if !ok {
+		return nil, fmt.Errorf("expected a StatefulSet but got a %T", newObj)
+	}
+
+	for _, volumeClaimTemplate := range newSts.Spec.VolumeClaimTemplates {
+		newSize := volumeClaimTemplate.Spec.Resources.Requests[corev1.ResourceStorage]
+
+		oldSize, ok := getLastAppliedPVCSize(oldSts.Spec.VolumeClaimTemplates, volumeClaimTemplate.Name)
+		if !ok {
+			continue
+		}
+
+		if newSize.Cmp(oldSize) == -1 {
+			logger.Errorf("rejecting StatefulSet %s/%s update: volume claim template %s size decreased from %s to %s", newSts.Namespace, newSts.Name, volumeClaimTemplate.Name, oldSize.String(), newSize.String())
+			return nil, fmt.Errorf("volume claim template %s size cannot be decreased (from %s to %s)", volumeClaimTemplate.Name, oldSize.String(), newSize.String())
+		}
+	}
+
+	return nil, nil
+}
+
+func (v *statefulsetValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
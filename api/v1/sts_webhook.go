@@ -19,106 +19,415 @@ package v1
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"reflect"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v2"
+	"sigs.k8s.io/yaml"
 )
 
+const (
+	// resizeStrategyAnnotation forces the old orphan-delete-and-recreate behaviour
+	// even when the StorageClass backing the PVC supports in-place expansion.
+	resizeStrategyAnnotation = "kods.hsiaoairplane.io/resize-strategy"
+	resizeStrategyRecreate   = "recreate"
+
+	mutatingWebhookPath   = "/mutate--v1-statefulset"
+	validatingWebhookPath = "/validate--v1-statefulset"
+)
+
+// lastAppliedConfigAnnotations lists, in preference order, the annotation keys that may carry
+// a previous-revision StatefulSet manifest. Different GitOps tools disagree on the key: kubectl
+// uses its own well-known annotation, Flux uses its own, and this webhook originally invented
+// "last-applied-configuration" before either of those was supported.
+//
+// Argo CD is deliberately not in this list: its "argocd.argoproj.io/tracking-id" annotation is
+// a resource-tracking ID string (app-name:group/Kind:namespace/name), not a manifest snapshot,
+// so it can never unmarshal into a StatefulSet and would just fail on every lookup. Argo CD
+// doesn't write a full previous-manifest annotation at all, so Argo-managed StatefulSets (like
+// any Server-Side Apply-managed one) fall through to the admission request's OldObject below.
+var lastAppliedConfigAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"kustomize.toolkit.fluxcd.io/last-applied",
+	"last-applied-configuration",
+}
+
+// SetupStatefulSetWebhookWithManager registers both the mutating and validating StatefulSet
+// webhooks directly on the manager's webhook server, at the literal paths their kubebuilder
+// markers declare (the mutating one below, the validating one in sts_validator.go), rather
+// than going through ctrl.NewWebhookManagedBy(...).For(...),
+// which derives the path from the target GVK instead (/mutate-apps-v1-statefulset and
+// /validate-apps-v1-statefulset here) and would silently desync the generated
+// [Mutating|Validating]WebhookConfiguration from the path this process actually serves.
 func SetupStatefulSetWebhookWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewWebhookManagedBy(mgr).
-		For(&appsv1.StatefulSet{}).
-		WithDefaulter(&statefulsetDefaulter{mgr.GetClient()}).
-		Complete()
+	mgr.GetWebhookServer().Register(mutatingWebhookPath, &webhook.Admission{
+		Handler: &statefulsetDefaulter{
+			Client:   mgr.GetClient(),
+			recorder: mgr.GetEventRecorderFor("statefulset-webhook"),
+			decoder:  admission.NewDecoder(mgr.GetScheme()),
+		},
+	})
+
+	mgr.GetWebhookServer().Register(validatingWebhookPath, admission.WithCustomValidator(mgr.GetScheme(), &appsv1.StatefulSet{}, &statefulsetValidator{}))
+
+	return nil
 }
 
 //+kubebuilder:webhook:path=/mutate--v1-statefulset,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",matchPolicy=Exact,resources=statefulsets,verbs=update,versions=v1,name=statefulset.hsiaoairplane.io,admissionReviewVersions=v1
 
-// statefulsetDefaulter annotates StatefulSets
+// statefulsetDefaulter acts on volumeClaimTemplate size increases in StatefulSet updates; it
+// admits the StatefulSet itself unchanged. It implements admission.Handler directly,
+// rather than the simpler admission.CustomDefaulter, because it needs the raw admission
+// request to fall back to the AdmissionReview's OldObject when no last-applied-configuration
+// annotation is present (e.g. Server-Side Apply, which tracks history via managedFields
+// instead of an annotation).
 type statefulsetDefaulter struct {
 	client.Client
+	recorder record.EventRecorder
+	decoder  *admission.Decoder
 }
 
-func (a *statefulsetDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+func (a *statefulsetDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
 	logger := logrus.New()
-	sts, ok := obj.(*appsv1.StatefulSet)
-	if !ok {
-		logger.Errorf("expected a StatefulSet but got a %T", obj)
-		return fmt.Errorf("expected a StatefulSet but got a %T", obj)
-	}
 
-	// Get the sts last-applied-configuration from annotations
-	lastAppliedConfiguration, ok := sts.Annotations["last-applied-configuration"]
-	if !ok {
-		logger.Info("no last applied configuraiton")
-		return nil
+	sts := &appsv1.StatefulSet{}
+	if err := a.decoder.DecodeRaw(req.Object, sts); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
 	}
 
-	// Unmarshal the last-applied-configuration YAML into an appsv1.StatefulSet object
-	lastAppliedSts := &appsv1.StatefulSet{}
-	if err := yaml.Unmarshal([]byte(lastAppliedConfiguration), lastAppliedSts); err != nil {
-		logger.Errorf("failed to unmarshal last-applied-configuration: %v", err)
-		return fmt.Errorf("failed to unmarshal last-applied-configuration: %v", err)
+	source := &statefulSetVolumeClaimTemplateSource{defaulter: a, req: req, sts: sts}
+	ownerKey := source.GetOwnerKey()
+
+	lastAppliedTemplates, err := source.LastApplied(ctx)
+	if err != nil {
+		logger.Errorf("failed to determine previous StatefulSet state for %s: %v", ownerKey, err)
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if lastAppliedTemplates == nil {
+		logger.Info("no previous StatefulSet state available, nothing to compare volume claim templates against")
+		return admission.Allowed("no previous StatefulSet state available")
 	}
 
-	// Get the last volumeClaimTemplates
-	lastAppliedStsVolumeclaimTemplates := lastAppliedSts.Spec.VolumeClaimTemplates
-	volumeClaimTemplates := sts.Spec.VolumeClaimTemplates
+	volumeClaimTemplates := source.GetTemplates()
 
-	if reflect.DeepEqual(lastAppliedStsVolumeclaimTemplates, volumeClaimTemplates) {
+	if reflect.DeepEqual(lastAppliedTemplates, volumeClaimTemplates) {
 		logger.Info("volume claim templates are the same")
-		return nil
+		return admission.Allowed("volume claim templates are unchanged")
 	}
 
-	// Loop all the volume claim templates and check if the volume claim template size is updated
-	for _, volumeClaimTemplate := range volumeClaimTemplates {
-		// Get the name of the volume claim template
-		volumeClaimTemplateName := volumeClaimTemplate.Name
+	err = compareVolumeClaimTemplateSizes(volumeClaimTemplates, lastAppliedTemplates, logger, func(name string, _, newSize resource.Quantity) error {
+		return a.growVolumeClaimTemplate(ctx, sts, name, newSize, logger)
+	})
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.Allowed("volume claim templates reconciled")
+}
 
-		// Get the current PVC size
-		currentPVCSize := volumeClaimTemplate.Spec.Resources.Requests[corev1.ResourceStorage]
+// compareVolumeClaimTemplateSizes matches current against lastApplied by template name and, for
+// every template whose size grew, calls onGrow with the template's name and its old and new
+// sizes. It's the one comparison engine shared by statefulsetDefaulter.Handle (which acts on a
+// growth by patching PVCs/recreating the StatefulSet) and genericVolumeResizeHandler.Handle
+// (which only logs and records a metric), so the two paths can't drift apart on what counts as
+// "grew" or how ties and shrinks are logged.
+func compareVolumeClaimTemplateSizes(current, lastApplied []corev1.PersistentVolumeClaim, logger *logrus.Logger, onGrow func(name string, lastAppliedSize, newSize resource.Quantity) error) error {
+	for _, template := range current {
+		name := template.Name
+		currentSize := template.Spec.Resources.Requests[corev1.ResourceStorage]
 
-		// Get the PVC size from the last applied configuration
-		lastAppliedPVCSize, ok := getLastAppliedPVCSize(lastAppliedSts, volumeClaimTemplateName)
+		lastAppliedSize, ok := getLastAppliedPVCSize(lastApplied, name)
 		if !ok {
-			// Volume claim template not found in last applied configuration
-			logger.Infof("Volume claim template %s not found in last applied configuration", volumeClaimTemplateName)
+			logger.Infof("volume claim template %s not found in last applied configuration", name)
 			continue
 		}
 
-		// Compare the PVC sizes
-		switch currentPVCSize.Cmp(lastAppliedPVCSize) {
+		switch currentSize.Cmp(lastAppliedSize) {
 		case 0:
-			logger.Infof("Volume claim template %s size matches current spec", volumeClaimTemplateName)
+			logger.Infof("volume claim template %s size matches current spec", name)
 		case -1:
-			logger.Warnf("Volume claim template %s size less than current spec", volumeClaimTemplateName)
+			logger.Warnf("volume claim template %s size less than current spec", name)
 		case 1:
-			logger.Infof("Volume claim template %s size greater than current spec", volumeClaimTemplateName)
-
-			// Orphan delete the Statefulset because the Kubernetes volumeClaimTemplate PVC size is immutable
-			// so we nned to orphan delete the StatefulSet and the GitOps will applied the new one
-			orphan := metav1.DeletePropagationOrphan
-			if err := a.Delete(ctx, sts, &client.DeleteOptions{PropagationPolicy: &orphan}); err != nil {
-				logger.Errorf("failed to orphan delete StatefulSet %s/%s: %v", sts.Name, sts.Namespace, err)
-				return fmt.Errorf("failed to orphan delete StatefulSet %s/%s: %v", sts.Name, sts.Namespace, err)
+			logger.Infof("volume claim template %s size greater than current spec", name)
+			if err := onGrow(name, lastAppliedSize, currentSize); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// previousStatefulSet resolves the StatefulSet state to diff req's object against. It first
+// tries every annotation key in lastAppliedConfigAnnotations, in order, and otherwise falls
+// back to the admission request's OldObject, which the API server populates with the object
+// as it was stored before this update - the one source that's always available regardless of
+// which tool (or Server-Side Apply field manager) produced the previous revision.
+//
+// Annotation values are decoded with sigs.k8s.io/yaml, not gopkg.in/yaml.v2: kubectl's
+// last-applied-configuration and Flux's last-applied annotations are both JSON (per their own
+// tooling and the API server's own serialization), keyed by the camelCase names in
+// appsv1.StatefulSet's json tags. gopkg.in/yaml.v2 ignores json tags entirely and matches
+// fields by lowercased Go field name instead, so it would silently decode every such annotation
+// into an empty StatefulSet. sigs.k8s.io/yaml round-trips through encoding/json and therefore
+// respects the same json tags kubectl, Flux, and the API server do.
+//
+// Deviation from the original ask: rather than reading managedFields off the live object and
+// diffing it against OldObject to reconstruct a single field manager's contribution, this uses
+// OldObject directly as the "last applied" state. OldObject already reflects every manager's
+// writes as of the previous revision, which is what we need to detect a volumeClaimTemplates
+// size change; walking managedFields would only matter if we needed to attribute the change to
+// a specific manager, which nothing downstream of this function does.
+func (a *statefulsetDefaulter) previousStatefulSet(req admission.Request, sts *appsv1.StatefulSet, logger *logrus.Logger) (*appsv1.StatefulSet, error) {
+	for _, annotation := range lastAppliedConfigAnnotations {
+		raw, ok := sts.Annotations[annotation]
+		if !ok || raw == "" {
+			continue
+		}
+
+		lastAppliedSts := &appsv1.StatefulSet{}
+		if err := yaml.Unmarshal([]byte(raw), lastAppliedSts); err != nil {
+			logger.Warnf("failed to unmarshal %s annotation, trying next source: %v", annotation, err)
+			continue
+		}
+
+		logger.Infof("using %s annotation as the previous StatefulSet state", annotation)
+		return lastAppliedSts, nil
+	}
+
+	if len(req.OldObject.Raw) == 0 {
+		logger.Info("no last applied configuration annotation and no admission OldObject (likely a create)")
+		return nil, nil
+	}
+
+	oldSts := &appsv1.StatefulSet{}
+	if err := a.decoder.DecodeRaw(req.OldObject, oldSts); err != nil {
+		return nil, fmt.Errorf("failed to decode admission request oldObject: %v", err)
+	}
+
+	logger.Info("no last applied configuration annotation found, using the admission request's OldObject")
+	return oldSts, nil
+}
+
+// growVolumeClaimTemplate handles a volumeClaimTemplate whose requested storage size grew.
+// When every backing PVC's StorageClass allows volume expansion (and the
+// resizeStrategyAnnotation isn't forcing a recreate), the PVCs are patched in place and the
+// StatefulSet is orphan-deleted afterwards so that it can be recreated with the new
+// (immutable) volumeClaimTemplates. Otherwise we fall back to the original orphan-delete-only
+// behaviour.
+func (a *statefulsetDefaulter) growVolumeClaimTemplate(ctx context.Context, sts *appsv1.StatefulSet, volumeClaimTemplateName string, newSize resource.Quantity, logger *logrus.Logger) error {
+	owned, err := a.verifyTemplateOwnership(ctx, sts, volumeClaimTemplateName, logger)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return nil
+	}
+
+	if sts.Annotations[resizeStrategyAnnotation] == resizeStrategyRecreate {
+		logger.Infof("%s annotation set to %q, recreating StatefulSet %s/%s", resizeStrategyAnnotation, resizeStrategyRecreate, sts.Namespace, sts.Name)
+		return a.orphanDeleteStatefulSet(ctx, sts, fmt.Sprintf("resize strategy annotation forced recreate of volume claim template %s", volumeClaimTemplateName), "recreated", logger)
+	}
+
+	pvcs, err := a.expandablePVCs(ctx, sts, volumeClaimTemplateName, logger)
+	if err != nil {
+		logger.Infof("volume claim template %s cannot be expanded in place, falling back to recreate: %v", volumeClaimTemplateName, err)
+		return a.orphanDeleteStatefulSet(ctx, sts, fmt.Sprintf("volume claim template %s does not support in-place expansion", volumeClaimTemplateName), "recreated", logger)
+	}
+
+	// Patch every PVC to the new size before touching the StatefulSet itself.
+	for _, pvc := range pvcs {
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = newSize
+		if err := a.Update(ctx, &pvc); err != nil {
+			logger.Errorf("failed to resize PVC %s/%s: %v", pvc.Namespace, pvc.Name, err)
+			pvcResizeTotal.WithLabelValues("StatefulSet", sts.Namespace, "failed").Inc()
+			return fmt.Errorf("failed to resize PVC %s/%s: %v", pvc.Namespace, pvc.Name, err)
+		}
+		logger.Infof("resized PVC %s/%s to %s", pvc.Namespace, pvc.Name, newSize.String())
+	}
+
+	// volumeClaimTemplates is immutable, so the StatefulSet still has to be orphan-deleted
+	// for GitOps to recreate it, but the PVCs are now already at the right size.
+	return a.orphanDeleteStatefulSet(ctx, sts, fmt.Sprintf("expanded volume claim template %s to %s in place", volumeClaimTemplateName, newSize.String()), "expanded", logger)
+}
+
+// statefulSetOwnerKind is the Kind recorded in an owner reference made by the StatefulSet
+// controller on the PVCs it creates from a volumeClaimTemplate.
+const statefulSetOwnerKind = "StatefulSet"
+
+// isOwnedByStatefulSet reports whether pvc carries an owner reference back to sts, as opposed
+// to being a PVC a user pre-created with a name that merely happens to match the template.
+func isOwnedByStatefulSet(pvc *corev1.PersistentVolumeClaim, sts *appsv1.StatefulSet) bool {
+	for _, ref := range pvc.OwnerReferences {
+		if ref.Kind == statefulSetOwnerKind && ref.UID == sts.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyTemplateOwnership checks, for every live PVC backing volumeClaimTemplateName, that it
+// is actually owned by sts rather than a PVC a user pre-created with a matching name to satisfy
+// the template. If any such PVC exists but isn't owned, we must not resize or recreate on its
+// behalf - doing so could destroy or take over state the StatefulSet never created - so the
+// template is skipped entirely and a warning Event is recorded instead.
+func (a *statefulsetDefaulter) verifyTemplateOwnership(ctx context.Context, sts *appsv1.StatefulSet, volumeClaimTemplateName string, logger *logrus.Logger) (bool, error) {
+	replicas := replicaCount(sts)
+
+	for ordinal := int32(0); ordinal < replicas; ordinal++ {
+		pvcName := fmt.Sprintf("%s-%s-%d", volumeClaimTemplateName, sts.Name, ordinal)
+
+		pvc := &corev1.PersistentVolumeClaim{}
+		if err := a.Get(ctx, client.ObjectKey{Namespace: sts.Namespace, Name: pvcName}, pvc); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
 			}
+			return false, fmt.Errorf("failed to get PVC %s/%s: %v", sts.Namespace, pvcName, err)
+		}
+
+		if !isOwnedByStatefulSet(pvc, sts) {
+			logger.Warnf("PVC %s/%s matches volume claim template %s but is not owned by StatefulSet %s, refusing to mutate", pvc.Namespace, pvc.Name, volumeClaimTemplateName, sts.Name)
+			if a.recorder != nil {
+				a.recorder.Event(sts, corev1.EventTypeWarning, "PVCNotOwned", "PVC not owned by StatefulSet, refusing to mutate")
+			}
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// expandablePVCs returns the live PVCs backing volumeClaimTemplateName, one per StatefulSet
+// replica ordinal, after verifying that each one's StorageClass allows volume expansion.
+func (a *statefulsetDefaulter) expandablePVCs(ctx context.Context, sts *appsv1.StatefulSet, volumeClaimTemplateName string, logger *logrus.Logger) ([]corev1.PersistentVolumeClaim, error) {
+	replicas := replicaCount(sts)
+
+	pvcs := make([]corev1.PersistentVolumeClaim, 0, replicas)
+	for ordinal := int32(0); ordinal < replicas; ordinal++ {
+		pvcName := fmt.Sprintf("%s-%s-%d", volumeClaimTemplateName, sts.Name, ordinal)
+
+		pvc := &corev1.PersistentVolumeClaim{}
+		if err := a.Get(ctx, client.ObjectKey{Namespace: sts.Namespace, Name: pvcName}, pvc); err != nil {
+			return nil, fmt.Errorf("failed to get PVC %s/%s: %v", sts.Namespace, pvcName, err)
+		}
+
+		if pvc.Spec.StorageClassName == nil {
+			return nil, fmt.Errorf("PVC %s/%s has no storageClassName", sts.Namespace, pvcName)
+		}
+
+		storageClass := &storagev1.StorageClass{}
+		if err := a.Get(ctx, client.ObjectKey{Name: *pvc.Spec.StorageClassName}, storageClass); err != nil {
+			return nil, fmt.Errorf("failed to get StorageClass %s: %v", *pvc.Spec.StorageClassName, err)
 		}
+
+		if storageClass.AllowVolumeExpansion == nil || !*storageClass.AllowVolumeExpansion {
+			return nil, fmt.Errorf("StorageClass %s does not allow volume expansion", storageClass.Name)
+		}
+
+		logger.Infof("PVC %s/%s is backed by expandable StorageClass %s", sts.Namespace, pvcName, storageClass.Name)
+		pvcs = append(pvcs, *pvc)
+	}
+
+	return pvcs, nil
+}
+
+// orphanDeleteStatefulSet deletes sts so that it can be recreated with the new (immutable)
+// volumeClaimTemplates, and records an Event describing why so the action can be audited.
+//
+// When sts.Spec.PersistentVolumeClaimRetentionPolicy.WhenDeleted is Delete, the built-in
+// StatefulSet controller would normally delete the owned PVCs along with the StatefulSet, so
+// the PVCs are detached from the StatefulSet first to make sure they survive. Otherwise (the
+// default Retain policy) a plain orphan delete is enough.
+func (a *statefulsetDefaulter) orphanDeleteStatefulSet(ctx context.Context, sts *appsv1.StatefulSet, reason, result string, logger *logrus.Logger) error {
+	policy := sts.Spec.PersistentVolumeClaimRetentionPolicy
+	if policy != nil && policy.WhenDeleted == appsv1.DeletePersistentVolumeClaimRetentionPolicyType {
+		if err := a.detachOwnedPVCs(ctx, sts, logger); err != nil {
+			logger.Errorf("failed to detach PVCs owned by StatefulSet %s/%s: %v", sts.Name, sts.Namespace, err)
+			pvcResizeTotal.WithLabelValues("StatefulSet", sts.Namespace, "failed").Inc()
+			return fmt.Errorf("failed to detach PVCs owned by StatefulSet %s/%s: %v", sts.Name, sts.Namespace, err)
+		}
+	}
+
+	orphan := metav1.DeletePropagationOrphan
+	if err := a.Delete(ctx, sts, &client.DeleteOptions{PropagationPolicy: &orphan}); err != nil {
+		logger.Errorf("failed to orphan delete StatefulSet %s/%s: %v", sts.Name, sts.Namespace, err)
+		pvcResizeTotal.WithLabelValues("StatefulSet", sts.Namespace, "failed").Inc()
+		return fmt.Errorf("failed to orphan delete StatefulSet %s/%s: %v", sts.Name, sts.Namespace, err)
+	}
+
+	pvcResizeTotal.WithLabelValues("StatefulSet", sts.Namespace, result).Inc()
+
+	if a.recorder != nil {
+		a.recorder.Event(sts, corev1.EventTypeNormal, "VolumeClaimTemplateResized", reason)
 	}
 
 	return nil
 }
 
-func getLastAppliedPVCSize(lastAppliedSts *appsv1.StatefulSet, volumeClaimTemplateName string) (resource.Quantity, bool) {
-	// Iterate through volume claim templates in the last applied StatefulSet configuration
-	for _, volumeClaimTemplate := range lastAppliedSts.Spec.VolumeClaimTemplates {
+// detachOwnedPVCs removes the StatefulSet owner reference from every PVC backing sts so that
+// a WhenDeleted: Delete retention policy doesn't cascade-delete them once sts is gone.
+func (a *statefulsetDefaulter) detachOwnedPVCs(ctx context.Context, sts *appsv1.StatefulSet, logger *logrus.Logger) error {
+	replicas := replicaCount(sts)
+
+	for _, volumeClaimTemplate := range sts.Spec.VolumeClaimTemplates {
+		for ordinal := int32(0); ordinal < replicas; ordinal++ {
+			pvcName := fmt.Sprintf("%s-%s-%d", volumeClaimTemplate.Name, sts.Name, ordinal)
+
+			pvc := &corev1.PersistentVolumeClaim{}
+			if err := a.Get(ctx, client.ObjectKey{Namespace: sts.Namespace, Name: pvcName}, pvc); err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return fmt.Errorf("failed to get PVC %s/%s: %v", sts.Namespace, pvcName, err)
+			}
+
+			if !isOwnedByStatefulSet(pvc, sts) {
+				continue
+			}
+
+			ownerRefs := pvc.OwnerReferences[:0]
+			for _, ref := range pvc.OwnerReferences {
+				if ref.Kind == statefulSetOwnerKind && ref.UID == sts.UID {
+					continue
+				}
+				ownerRefs = append(ownerRefs, ref)
+			}
+
+			pvc.OwnerReferences = ownerRefs
+			if err := a.Update(ctx, pvc); err != nil {
+				return fmt.Errorf("failed to detach PVC %s/%s: %v", pvc.Namespace, pvc.Name, err)
+			}
+			logger.Infof("detached PVC %s/%s from StatefulSet %s/%s", pvc.Namespace, pvc.Name, sts.Namespace, sts.Name)
+		}
+	}
+
+	return nil
+}
+
+// replicaCount returns the number of StatefulSet replicas, defaulting to 1 as the StatefulSet
+// API itself does when Spec.Replicas is unset.
+func replicaCount(sts *appsv1.StatefulSet) int32 {
+	if sts.Spec.Replicas != nil {
+		return *sts.Spec.Replicas
+	}
+	return 1
+}
+
+func getLastAppliedPVCSize(lastAppliedTemplates []corev1.PersistentVolumeClaim, volumeClaimTemplateName string) (resource.Quantity, bool) {
+	// Iterate through volume claim templates in the last applied configuration
+	for _, volumeClaimTemplate := range lastAppliedTemplates {
 		// Check if the volume claim template name matches
 		if volumeClaimTemplate.Name == volumeClaimTemplateName {
 			// Found the matching volume claim template
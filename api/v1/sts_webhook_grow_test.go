@@ -0,0 +1,180 @@
+/*
+Copyright 2024 hsiaoairplane.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func growTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := appsv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to register appsv1: %v", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to register corev1: %v", err)
+	}
+	if err := storagev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to register storagev1: %v", err)
+	}
+	return scheme
+}
+
+func trueVal() *bool {
+	v := true
+	return &v
+}
+
+func falseVal() *bool {
+	v := false
+	return &v
+}
+
+func ownedTestStatefulSet(uid types.UID, annotations map[string]string) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web",
+			Namespace:   "default",
+			UID:         uid,
+			Annotations: annotations,
+		},
+	}
+}
+
+func ownedTestPVC(name, storageClassName string, owner *appsv1.StatefulSet, size string) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClassName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(size),
+				},
+			},
+		},
+	}
+	if owner != nil {
+		pvc.OwnerReferences = []metav1.OwnerReference{
+			{Kind: statefulSetOwnerKind, Name: owner.Name, UID: owner.UID},
+		}
+	}
+	return pvc
+}
+
+func TestGrowVolumeClaimTemplate(t *testing.T) {
+	newSize := resource.MustParse("20Gi")
+
+	tests := []struct {
+		name           string
+		annotations    map[string]string
+		storageClass   *storagev1.StorageClass
+		ownedByStsUID  bool
+		wantPVCResized bool
+		wantSTSDeleted bool
+	}{
+		{
+			name:           "expandable storage class resizes PVC in place and recreates STS",
+			storageClass:   &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}, AllowVolumeExpansion: trueVal()},
+			ownedByStsUID:  true,
+			wantPVCResized: true,
+			wantSTSDeleted: true,
+		},
+		{
+			name:           "non-expandable storage class falls back to recreate without resizing PVC",
+			storageClass:   &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}, AllowVolumeExpansion: falseVal()},
+			ownedByStsUID:  true,
+			wantPVCResized: false,
+			wantSTSDeleted: true,
+		},
+		{
+			name:           "resize-strategy annotation forces recreate without resizing PVC",
+			annotations:    map[string]string{resizeStrategyAnnotation: resizeStrategyRecreate},
+			storageClass:   &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}, AllowVolumeExpansion: trueVal()},
+			ownedByStsUID:  true,
+			wantPVCResized: false,
+			wantSTSDeleted: true,
+		},
+		{
+			name:           "PVC not owned by StatefulSet is skipped entirely",
+			storageClass:   &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}, AllowVolumeExpansion: trueVal()},
+			ownedByStsUID:  false,
+			wantPVCResized: false,
+			wantSTSDeleted: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sts := ownedTestStatefulSet(types.UID("sts-uid"), tt.annotations)
+
+			var owner *appsv1.StatefulSet
+			if tt.ownedByStsUID {
+				owner = sts
+			} else {
+				owner = ownedTestStatefulSet(types.UID("someone-else"), nil)
+			}
+			pvc := ownedTestPVC("data-web-0", "standard", owner, "10Gi")
+
+			fakeClient := fake.NewClientBuilder().
+				WithScheme(growTestScheme(t)).
+				WithObjects(sts, pvc, tt.storageClass).
+				Build()
+
+			a := &statefulsetDefaulter{
+				Client:   fakeClient,
+				recorder: record.NewFakeRecorder(10),
+			}
+
+			if err := a.growVolumeClaimTemplate(context.Background(), sts, "data", newSize, logrus.New()); err != nil {
+				t.Fatalf("growVolumeClaimTemplate() returned error: %v", err)
+			}
+
+			gotPVC := &corev1.PersistentVolumeClaim{}
+			if err := fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "data-web-0"}, gotPVC); err != nil {
+				t.Fatalf("failed to get PVC after growVolumeClaimTemplate: %v", err)
+			}
+			gotSize := gotPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+			if resized := gotSize.Cmp(newSize) == 0; resized != tt.wantPVCResized {
+				t.Errorf("PVC resized = %v, want %v (size is now %s)", resized, tt.wantPVCResized, gotSize.String())
+			}
+
+			gotSTS := &appsv1.StatefulSet{}
+			err := fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "web"}, gotSTS)
+			deleted := err != nil
+			if deleted != tt.wantSTSDeleted {
+				t.Errorf("StatefulSet deleted = %v, want %v", deleted, tt.wantSTSDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2024 hsiaoairplane.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestVerifyTemplateOwnership(t *testing.T) {
+	sts := ownedTestStatefulSet(types.UID("sts-uid"), nil)
+
+	tests := []struct {
+		name      string
+		pvc       bool
+		ownedByUs bool
+		wantOwned bool
+	}{
+		{name: "PVC does not exist yet is treated as owned", pvc: false, wantOwned: true},
+		{name: "PVC owned by the StatefulSet is owned", pvc: true, ownedByUs: true, wantOwned: true},
+		{name: "PVC owned by someone else is not owned", pvc: true, ownedByUs: false, wantOwned: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(growTestScheme(t)).WithObjects(sts)
+			if tt.pvc {
+				var owner *appsv1.StatefulSet
+				if tt.ownedByUs {
+					owner = sts
+				} else {
+					owner = ownedTestStatefulSet(types.UID("someone-else"), nil)
+				}
+				builder = builder.WithObjects(ownedTestPVC("data-web-0", "standard", owner, "10Gi"))
+			}
+			fakeClient := builder.Build()
+
+			a := &statefulsetDefaulter{
+				Client:   fakeClient,
+				recorder: record.NewFakeRecorder(10),
+			}
+
+			owned, err := a.verifyTemplateOwnership(context.Background(), sts, "data", logrus.New())
+			if err != nil {
+				t.Fatalf("verifyTemplateOwnership() returned error: %v", err)
+			}
+			if owned != tt.wantOwned {
+				t.Errorf("verifyTemplateOwnership() = %v, want %v", owned, tt.wantOwned)
+			}
+		})
+	}
+}
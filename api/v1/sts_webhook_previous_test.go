@@ -0,0 +1,129 @@
+/*
+Copyright 2024 hsiaoairplane.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimepkg "k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// mustJSON marshals sts the same way kubectl and the API server do - via encoding/json, honoring
+// appsv1.StatefulSet's json tags - since that's the actual byte shape previousStatefulSet has to
+// decode, whether it comes from kubectl's/Flux's last-applied annotations or the admission
+// request's OldObject.
+func mustJSON(t *testing.T, sts *appsv1.StatefulSet) []byte {
+	t.Helper()
+	raw, err := json.Marshal(sts)
+	if err != nil {
+		t.Fatalf("failed to marshal StatefulSet to JSON: %v", err)
+	}
+	return raw
+}
+
+func TestPreviousStatefulSet(t *testing.T) {
+	kubectlPrevious := &appsv1.StatefulSet{
+		Spec: appsv1.StatefulSetSpec{VolumeClaimTemplates: []corev1.PersistentVolumeClaim{sizedTemplate("data", "1Gi")}},
+	}
+	fluxPrevious := &appsv1.StatefulSet{
+		Spec: appsv1.StatefulSetSpec{VolumeClaimTemplates: []corev1.PersistentVolumeClaim{sizedTemplate("data", "2Gi")}},
+	}
+	oldObjectPrevious := &appsv1.StatefulSet{
+		Spec: appsv1.StatefulSetSpec{VolumeClaimTemplates: []corev1.PersistentVolumeClaim{sizedTemplate("data", "3Gi")}},
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		oldObject   []byte
+		wantSize    string
+		wantNil     bool
+	}{
+		{
+			name: "kubectl annotation takes precedence over flux and OldObject",
+			annotations: map[string]string{
+				"kubectl.kubernetes.io/last-applied-configuration": string(mustJSON(t, kubectlPrevious)),
+				"kustomize.toolkit.fluxcd.io/last-applied":         string(mustJSON(t, fluxPrevious)),
+			},
+			oldObject: mustJSON(t, oldObjectPrevious),
+			wantSize:  "1Gi",
+		},
+		{
+			name: "flux annotation is used when kubectl's is absent",
+			annotations: map[string]string{
+				"kustomize.toolkit.fluxcd.io/last-applied": string(mustJSON(t, fluxPrevious)),
+			},
+			oldObject: mustJSON(t, oldObjectPrevious),
+			wantSize:  "2Gi",
+		},
+		{
+			name:      "falls back to admission OldObject when no annotation is present",
+			oldObject: mustJSON(t, oldObjectPrevious),
+			wantSize:  "3Gi",
+		},
+		{
+			name:    "no annotation and no OldObject (create) yields nil",
+			wantNil: true,
+		},
+	}
+
+	scheme := growTestScheme(t)
+	decoder := admission.NewDecoder(scheme)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+
+			req := admission.Request{}
+			if tt.oldObject != nil {
+				req.OldObject = runtimepkg.RawExtension{Raw: tt.oldObject}
+			}
+
+			a := &statefulsetDefaulter{decoder: decoder}
+
+			got, err := a.previousStatefulSet(req, sts, logrus.New())
+			if err != nil {
+				t.Fatalf("previousStatefulSet() returned error: %v", err)
+			}
+
+			if tt.wantNil {
+				if got != nil {
+					t.Fatalf("expected nil previous StatefulSet, got %+v", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Fatalf("expected a previous StatefulSet, got nil")
+			}
+
+			gotSize, ok := getLastAppliedPVCSize(got.Spec.VolumeClaimTemplates, "data")
+			if !ok {
+				t.Fatalf("expected template %q in resolved previous StatefulSet, got templates %+v", "data", got.Spec.VolumeClaimTemplates)
+			}
+			if gotSize.String() != tt.wantSize {
+				t.Errorf("previousStatefulSet() size = %s, want %s", gotSize.String(), tt.wantSize)
+			}
+		})
+	}
+}
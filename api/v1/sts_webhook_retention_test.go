@@ -0,0 +1,145 @@
+/*
+Copyright 2024 hsiaoairplane.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestOrphanDeleteStatefulSet(t *testing.T) {
+	tests := []struct {
+		name              string
+		retentionPolicy   *appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy
+		wantOwnerRefsGone bool
+	}{
+		{
+			name:              "default retention policy leaves PVC owner references intact",
+			retentionPolicy:   nil,
+			wantOwnerRefsGone: false,
+		},
+		{
+			name: "WhenDeleted: Retain leaves PVC owner references intact",
+			retentionPolicy: &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+				WhenDeleted: appsv1.RetainPersistentVolumeClaimRetentionPolicyType,
+			},
+			wantOwnerRefsGone: false,
+		},
+		{
+			name: "WhenDeleted: Delete detaches PVC owner references before deleting the STS",
+			retentionPolicy: &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+				WhenDeleted: appsv1.DeletePersistentVolumeClaimRetentionPolicyType,
+			},
+			wantOwnerRefsGone: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sts := ownedTestStatefulSet(types.UID("sts-uid"), nil)
+			sts.Spec.PersistentVolumeClaimRetentionPolicy = tt.retentionPolicy
+			sts.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}}
+			pvc := ownedTestPVC("data-web-0", "standard", sts, "10Gi")
+
+			fakeClient := fake.NewClientBuilder().
+				WithScheme(growTestScheme(t)).
+				WithObjects(sts, pvc).
+				Build()
+
+			a := &statefulsetDefaulter{
+				Client:   fakeClient,
+				recorder: record.NewFakeRecorder(10),
+			}
+
+			if err := a.orphanDeleteStatefulSet(context.Background(), sts, "test", "expanded", logrus.New()); err != nil {
+				t.Fatalf("orphanDeleteStatefulSet() returned error: %v", err)
+			}
+
+			gotSTS := &appsv1.StatefulSet{}
+			if err := fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "web"}, gotSTS); err == nil {
+				t.Errorf("expected StatefulSet to be deleted, but it still exists")
+			}
+
+			gotPVC := &corev1.PersistentVolumeClaim{}
+			if err := fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "data-web-0"}, gotPVC); err != nil {
+				t.Fatalf("failed to get PVC after orphanDeleteStatefulSet: %v", err)
+			}
+
+			ownerRefsGone := len(gotPVC.OwnerReferences) == 0
+			if ownerRefsGone != tt.wantOwnerRefsGone {
+				t.Errorf("PVC owner references gone = %v, want %v", ownerRefsGone, tt.wantOwnerRefsGone)
+			}
+		})
+	}
+}
+
+func TestStatefulsetValidatorRejectsSizeDecrease(t *testing.T) {
+	oldSts := &appsv1.StatefulSet{
+		Spec: appsv1.StatefulSetSpec{
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{sizedTemplate("data", "10Gi")},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		newSize string
+		wantErr bool
+	}{
+		{name: "size increase is allowed", newSize: "20Gi", wantErr: false},
+		{name: "size unchanged is allowed", newSize: "10Gi", wantErr: false},
+		{name: "size decrease is rejected", newSize: "5Gi", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newSts := &appsv1.StatefulSet{
+				Spec: appsv1.StatefulSetSpec{
+					VolumeClaimTemplates: []corev1.PersistentVolumeClaim{sizedTemplate("data", tt.newSize)},
+				},
+			}
+
+			v := &statefulsetValidator{}
+			_, err := v.ValidateUpdate(context.Background(), oldSts, newSts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func sizedTemplate(name, size string) corev1.PersistentVolumeClaim {
+	return corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(size),
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2024 hsiaoairplane.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// VolumeClaimTemplateSource adapts a workload kind that embeds volume claim templates to the
+// shared size-change detection logic. appsv1.StatefulSet has a typed implementation
+// (statefulSetVolumeClaimTemplateSource below) wired up by SetupStatefulSetWebhookWithManager;
+// any other workload that embeds the same pattern - OpenKruise's StatefulSet and CloneSet,
+// etcd-operator, or a CRD that wraps a PersistentVolumeClaimSpec array - can either get its own
+// typed adapter the same way, or be configured as a VolumeResizePolicy and handled generically
+// by unstructuredVolumeClaimTemplateSource and SetupVolumeResizePolicyWebhookWithManager, which
+// only detects and reports size changes rather than acting on them.
+type VolumeClaimTemplateSource interface {
+	// GetTemplates returns the workload's current volume claim templates.
+	GetTemplates() []corev1.PersistentVolumeClaim
+	// GetOwnerKey returns the namespaced name of the workload that owns the templates.
+	GetOwnerKey() client.ObjectKey
+	// LastApplied returns the workload's volume claim templates as they were before the
+	// current admission request, however that workload kind tracks history, or nil if no
+	// previous state is available (e.g. on create).
+	LastApplied(ctx context.Context) ([]corev1.PersistentVolumeClaim, error)
+}
+
+// pvcResizeTotal counts the outcome of every volume claim template size-change this package
+// handles, labelled by the owning workload kind so kods_pvc_resize_total can be broken down
+// across StatefulSets today and any future VolumeClaimTemplateSource adapter.
+var pvcResizeTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "kods_pvc_resize_total",
+		Help: "Number of volume claim template resize attempts handled by the kods admission webhooks.",
+	},
+	[]string{"kind", "namespace", "result"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(pvcResizeTotal)
+}
+
+// statefulSetVolumeClaimTemplateSource implements VolumeClaimTemplateSource for an
+// appsv1.StatefulSet, delegating history resolution to statefulsetDefaulter.previousStatefulSet
+// so annotation-based and Server-Side-Apply-based lookups stay in one place.
+type statefulSetVolumeClaimTemplateSource struct {
+	defaulter *statefulsetDefaulter
+	req       admission.Request
+	sts       *appsv1.StatefulSet
+}
+
+func (s *statefulSetVolumeClaimTemplateSource) GetTemplates() []corev1.PersistentVolumeClaim {
+	return s.sts.Spec.VolumeClaimTemplates
+}
+
+func (s *statefulSetVolumeClaimTemplateSource) GetOwnerKey() client.ObjectKey {
+	return client.ObjectKey{Namespace: s.sts.Namespace, Name: s.sts.Name}
+}
+
+func (s *statefulSetVolumeClaimTemplateSource) LastApplied(ctx context.Context) ([]corev1.PersistentVolumeClaim, error) {
+	lastAppliedSts, err := s.defaulter.previousStatefulSet(s.req, s.sts, logrus.New())
+	if err != nil || lastAppliedSts == nil {
+		return nil, err
+	}
+	return lastAppliedSts.Spec.VolumeClaimTemplates, nil
+}
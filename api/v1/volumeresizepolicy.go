@@ -0,0 +1,195 @@
+/*
+Copyright 2024 hsiaoairplane.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/sirupsen/logrus"
+)
+
+// VolumeResizePolicy configures generic volume claim template size-change detection for a
+// workload kind this package has no typed VolumeClaimTemplateSource for - OpenKruise's
+// StatefulSet and AdvancedStatefulSet, etcd-operator, or any CRD that embeds a
+// PersistentVolumeClaimSpec array. It stands in for the VolumeResizePolicy CRD the original
+// request asked for: this repo doesn't vendor a controller-gen/CRD codegen pipeline to produce
+// and register an actual CRD type, so policies are supplied in Go to
+// SetupVolumeResizePolicyWebhookWithManager instead of being read live from the cluster.
+//
+// Known gap, not yet signed off on as a rescoping: the original request also asked for a typed
+// OpenKruise StatefulSet adapter alongside this generic one. No OpenKruise dependency has been
+// added and no typed adapter exists - today an OpenKruise StatefulSet can only be covered by a
+// VolumeResizePolicy, which detects and logs a size increase but never acts on it (see
+// genericVolumeResizeHandler). Whether that detect-only coverage is an acceptable substitute for
+// the typed adapter the request asked for is a product decision this package can't make for
+// itself; until someone signs off on it explicitly, treat the OpenKruise adapter as outstanding
+// rather than done.
+type VolumeResizePolicy struct {
+	// Name identifies the policy in logs, and in the kods_pvc_resize_total kind label when
+	// GroupVersionKind.Kind is empty.
+	Name string
+	// GroupVersionKind is the workload kind this policy applies to.
+	GroupVersionKind schema.GroupVersionKind
+	// VolumeClaimTemplatesPath is the unstructured field path to the
+	// []corev1.PersistentVolumeClaim-shaped slice within the workload, e.g.
+	// []string{"spec", "volumeClaimTemplates"}.
+	VolumeClaimTemplatesPath []string
+	// WebhookPath is the literal path this policy's webhook is registered and served at.
+	WebhookPath string
+}
+
+// unstructuredVolumeClaimTemplateSource implements VolumeClaimTemplateSource for a workload kind
+// configured by a VolumeResizePolicy, reading its volume claim templates out of an
+// *unstructured.Unstructured object rather than a typed Go struct.
+type unstructuredVolumeClaimTemplateSource struct {
+	policy VolumeResizePolicy
+	req    admission.Request
+	obj    *unstructured.Unstructured
+}
+
+func (s *unstructuredVolumeClaimTemplateSource) GetTemplates() []corev1.PersistentVolumeClaim {
+	templates, err := nestedVolumeClaimTemplates(s.obj.Object, s.policy.VolumeClaimTemplatesPath)
+	if err != nil {
+		return nil
+	}
+	return templates
+}
+
+func (s *unstructuredVolumeClaimTemplateSource) GetOwnerKey() client.ObjectKey {
+	return client.ObjectKey{Namespace: s.obj.GetNamespace(), Name: s.obj.GetName()}
+}
+
+// LastApplied reads the workload's volume claim templates as of the admission request's
+// OldObject. Unlike statefulsetDefaulter.previousStatefulSet, it doesn't also try
+// lastAppliedConfigAnnotations: those annotations carry a full typed manifest, and re-deriving
+// them generically for an arbitrary unstructured kind would need the same per-kind knowledge
+// this adapter exists to avoid requiring.
+func (s *unstructuredVolumeClaimTemplateSource) LastApplied(ctx context.Context) ([]corev1.PersistentVolumeClaim, error) {
+	if len(s.req.OldObject.Raw) == 0 {
+		return nil, nil
+	}
+
+	oldObj := &unstructured.Unstructured{}
+	if err := oldObj.UnmarshalJSON(s.req.OldObject.Raw); err != nil {
+		return nil, fmt.Errorf("failed to decode admission request oldObject: %v", err)
+	}
+
+	return nestedVolumeClaimTemplates(oldObj.Object, s.policy.VolumeClaimTemplatesPath)
+}
+
+// nestedVolumeClaimTemplates reads the slice at path within obj and converts each entry from its
+// unstructured form into a corev1.PersistentVolumeClaim.
+func nestedVolumeClaimTemplates(obj map[string]interface{}, path []string) ([]corev1.PersistentVolumeClaim, error) {
+	raw, found, err := unstructured.NestedSlice(obj, path...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v: %v", path, err)
+	}
+	if !found {
+		return nil, nil
+	}
+
+	templates := make([]corev1.PersistentVolumeClaim, 0, len(raw))
+	for i, item := range raw {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%v[%d] is not an object", path, i)
+		}
+
+		var template corev1.PersistentVolumeClaim
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, &template); err != nil {
+			return nil, fmt.Errorf("failed to convert %v[%d]: %v", path, i, err)
+		}
+		templates = append(templates, template)
+	}
+
+	return templates, nil
+}
+
+// genericVolumeResizeHandler detects volume claim template size changes for workload kinds
+// configured via a VolumeResizePolicy, using the same size comparison as
+// statefulsetDefaulter.Handle. Unlike the StatefulSet adapter, it only detects and reports a
+// size increase - it never patches PVCs or recreates the workload, because the
+// "<template>-<name>-<ordinal>" PVC naming and orphan-delete-and-recreate dance in
+// growVolumeClaimTemplate is specific to how appsv1.StatefulSet manages its pods and PVCs, and
+// can't be assumed for an arbitrary CRD. Giving a new workload kind (OpenKruise's StatefulSet,
+// say) the same in-place-resize behavior means adding a typed VolumeClaimTemplateSource
+// implementation for it, the way statefulSetVolumeClaimTemplateSource does - not extending this
+// generic path.
+type genericVolumeResizeHandler struct {
+	policy VolumeResizePolicy
+}
+
+func (h *genericVolumeResizeHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	logger := logrus.New()
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(req.Object.Raw); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	source := &unstructuredVolumeClaimTemplateSource{policy: h.policy, req: req, obj: obj}
+	ownerKey := source.GetOwnerKey()
+
+	lastAppliedTemplates, err := source.LastApplied(ctx)
+	if err != nil {
+		logger.Errorf("failed to determine previous state for %s %s: %v", h.policy.Name, ownerKey, err)
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if lastAppliedTemplates == nil {
+		return admission.Allowed("no previous state available")
+	}
+
+	kind := h.policy.GroupVersionKind.Kind
+	if kind == "" {
+		kind = h.policy.Name
+	}
+
+	_ = compareVolumeClaimTemplateSizes(source.GetTemplates(), lastAppliedTemplates, logger, func(name string, lastAppliedSize, newSize resource.Quantity) error {
+		logger.Infof("%s %s: volume claim template %s grew from %s to %s, but policy %q has no typed resize adapter - detection only",
+			kind, ownerKey, name, lastAppliedSize.String(), newSize.String(), h.policy.Name)
+		pvcResizeTotal.WithLabelValues(kind, ownerKey.Namespace, "detected").Inc()
+		return nil
+	})
+
+	return admission.Allowed("volume claim template sizes compared")
+}
+
+// SetupVolumeResizePolicyWebhookWithManager registers a genericVolumeResizeHandler for each
+// policy, at that policy's own WebhookPath, so a cluster operator can opt additional workload
+// kinds into volume claim template size-change detection without this package needing a typed
+// VolumeClaimTemplateSource for every one up front.
+func SetupVolumeResizePolicyWebhookWithManager(mgr ctrl.Manager, policies []VolumeResizePolicy) error {
+	for _, policy := range policies {
+		mgr.GetWebhookServer().Register(policy.WebhookPath, &webhook.Admission{
+			Handler: &genericVolumeResizeHandler{policy: policy},
+		})
+	}
+
+	return nil
+}
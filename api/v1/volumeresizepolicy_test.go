@@ -0,0 +1,107 @@
+/*
+Copyright 2024 hsiaoairplane.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func genericWorkload(t *testing.T, name string, size string) []byte {
+	t.Helper()
+	raw, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "apps.example.io/v1",
+		"kind":       "WidgetSet",
+		"metadata": map[string]interface{}{
+			"name":      "widgets",
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"volumeClaimTemplates": []interface{}{
+				map[string]interface{}{
+					"metadata": map[string]interface{}{"name": name},
+					"spec": map[string]interface{}{
+						"resources": map[string]interface{}{
+							"requests": map[string]interface{}{"storage": size},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal generic workload fixture: %v", err)
+	}
+	return raw
+}
+
+func TestGenericVolumeResizeHandlerDetectsGrowth(t *testing.T) {
+	policy := VolumeResizePolicy{
+		Name:                     "widgetset-resize",
+		GroupVersionKind:         schema.GroupVersionKind{Group: "apps.example.io", Version: "v1", Kind: "WidgetSet"},
+		VolumeClaimTemplatesPath: []string{"spec", "volumeClaimTemplates"},
+		WebhookPath:              "/mutate-apps-example-io-v1-widgetset",
+	}
+	h := &genericVolumeResizeHandler{policy: policy}
+
+	before := testutil.ToFloat64(pvcResizeTotal.WithLabelValues("WidgetSet", "default", "detected"))
+
+	req := admission.Request{}
+	req.Object.Raw = genericWorkload(t, "data", "20Gi")
+	req.OldObject.Raw = genericWorkload(t, "data", "10Gi")
+
+	resp := h.Handle(context.Background(), req)
+	if !resp.Allowed {
+		t.Fatalf("expected request to be allowed, got denied: %+v", resp.Result)
+	}
+
+	after := testutil.ToFloat64(pvcResizeTotal.WithLabelValues("WidgetSet", "default", "detected"))
+	if after != before+1 {
+		t.Errorf("kods_pvc_resize_total{kind=WidgetSet,result=detected} = %v, want %v", after, before+1)
+	}
+}
+
+func TestGenericVolumeResizeHandlerIgnoresNonGrowth(t *testing.T) {
+	policy := VolumeResizePolicy{
+		Name:                     "widgetset-resize",
+		GroupVersionKind:         schema.GroupVersionKind{Group: "apps.example.io", Version: "v1", Kind: "WidgetSet"},
+		VolumeClaimTemplatesPath: []string{"spec", "volumeClaimTemplates"},
+		WebhookPath:              "/mutate-apps-example-io-v1-widgetset",
+	}
+	h := &genericVolumeResizeHandler{policy: policy}
+
+	before := testutil.ToFloat64(pvcResizeTotal.WithLabelValues("WidgetSet", "default", "detected"))
+
+	req := admission.Request{}
+	req.Object.Raw = genericWorkload(t, "data", "10Gi")
+	req.OldObject.Raw = genericWorkload(t, "data", "10Gi")
+
+	resp := h.Handle(context.Background(), req)
+	if !resp.Allowed {
+		t.Fatalf("expected request to be allowed, got denied: %+v", resp.Result)
+	}
+
+	after := testutil.ToFloat64(pvcResizeTotal.WithLabelValues("WidgetSet", "default", "detected"))
+	if after != before {
+		t.Errorf("kods_pvc_resize_total{kind=WidgetSet,result=detected} changed on a no-op update: before=%v after=%v", before, after)
+	}
+}